@@ -0,0 +1,63 @@
+/*
+* apierror.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// APIResult is a single element of the array the bridge returns from a
+// PUT, POST, or DELETE. Batched requests (e.g. setting several light
+// attributes in one PUT) return one APIResult per attribute, so a single
+// request can partially succeed.
+type APIResult struct {
+	Success map[string]json.RawMessage `json:"success,omitempty"`
+	Error   *APIError                  `json:"error,omitempty"`
+}
+
+// APIResponse is the bridge's top-level response shape for PUT, POST, and
+// DELETE requests.
+type APIResponse []APIResult
+
+// APIError is a single error reported by the bridge, as documented at
+// http://www.developers.meethue.com/documentation/error-messages
+type APIError struct {
+	Type        int    `json:"type"`
+	Address     string `json:"address"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error type %d at %s: %s", e.Type, e.Address, e.Description)
+}
+
+// APIErrors collects every APIError found in a single APIResponse, so
+// that batched requests which partially fail surface all of their
+// failures rather than just the first one.
+type APIErrors []*APIError
+
+func (e APIErrors) Error() string {
+	descriptions := make([]string, len(e))
+	for i, err := range e {
+		descriptions[i] = err.Error()
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// errorsFromResponse collects the APIError of every failed result in resp.
+func errorsFromResponse(resp APIResponse) APIErrors {
+	var errs APIErrors
+	for _, result := range resp {
+		if result.Error != nil {
+			errs = append(errs, result.Error)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,87 @@
+/*
+* apierror_test.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestHandleResponseSuccess(t *testing.T) {
+	body, reader, err := HandleResponse(newResponse(`[{"success":{"/lights/1/state/on":true}}]`))
+	assert.NoError(t, err)
+	assert.NotNil(t, reader)
+	assert.JSONEq(t, `[{"success":{"/lights/1/state/on":true}}]`, string(body))
+}
+
+func TestHandleResponseSingleError(t *testing.T) {
+	body, reader, err := HandleResponse(newResponse(
+		`[{"error":{"type":1,"address":"/lights/1/state","description":"unauthorized user"}}]`,
+	))
+	assert.Nil(t, reader)
+	assert.Empty(t, body)
+	assert.Error(t, err)
+
+	var apiErrs APIErrors
+	assert.True(t, errors.As(err, &apiErrs))
+	assert.Len(t, apiErrs, 1)
+	assert.Equal(t, 1, apiErrs[0].Type)
+	assert.Equal(t, "/lights/1/state", apiErrs[0].Address)
+	assert.Equal(t, "unauthorized user", apiErrs[0].Description)
+}
+
+func TestHandleResponseBatchedPartialFailure(t *testing.T) {
+	// A batched PUT setting multiple attributes at once can succeed on
+	// some and fail on others within the same response array.
+	body := `[
+		{"success":{"/lights/1/state/bri":200}},
+		{"error":{"type":201,"address":"/lights/1/state/hue","description":"parameter not available"}},
+		{"error":{"type":201,"address":"/lights/1/state/sat","description":"parameter not available"}}
+	]`
+	_, _, err := HandleResponse(newResponse(body))
+	assert.Error(t, err)
+
+	var apiErrs APIErrors
+	assert.True(t, errors.As(err, &apiErrs))
+	assert.Len(t, apiErrs, 2)
+}
+
+func TestHandleResponseNonArrayBodyFallsThrough(t *testing.T) {
+	// A body that isn't shaped like the PUT/POST/DELETE result array is
+	// handed back raw by HandleResponse rather than erroring out on it.
+	body, reader, err := HandleResponse(newResponse(`{"not":"an api response array"}`))
+	assert.NoError(t, err)
+	assert.NotNil(t, reader)
+	assert.JSONEq(t, `{"not":"an api response array"}`, string(body))
+}
+
+func TestAPIErrorError(t *testing.T) {
+	err := &APIError{Type: 3, Address: "/lights", Description: "resource not available"}
+	assert.Equal(t, "error type 3 at /lights: resource not available", err.Error())
+}
+
+func TestAPIErrorsError(t *testing.T) {
+	errs := APIErrors{
+		&APIError{Type: 1, Address: "/a", Description: "first"},
+		&APIError{Type: 2, Address: "/b", Description: "second"},
+	}
+	assert.Equal(t, "error type 1 at /a: first; error type 2 at /b: second", errs.Error())
+}
@@ -13,6 +13,7 @@ package hue
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -24,13 +25,25 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
+// defaultApplyConcurrency caps the number of concurrent PUTs issued by
+// ApplyLightStates when Bridge.Concurrency is left unset. The Hue bridge
+// can only reliably sustain around 10 requests/second, so this stays
+// conservative.
+const defaultApplyConcurrency = 4
+
 // Bridge struct defines hardware that is used to communicate with the lights.
 type Bridge struct {
 	IPAddress string `json:"internalipaddress"`
 	Username  string // Token from Bridge.CreateUser
 	Info      BridgeInfo
+
+	// Concurrency caps how many requests ApplyLightStates will have in
+	// flight at once. Zero (the default) falls back to defaultApplyConcurrency.
+	Concurrency int
 }
 
 // BridgeInfo struct is the format for parsing xml from a bridge.
@@ -61,10 +74,22 @@ func (b *Bridge) uri(path string) string {
 
 // Get sends a http GET to the bridge
 func (bridge *Bridge) Get(path string) ([]byte, io.Reader, error) {
+	return bridge.GetCtx(context.Background(), path)
+}
+
+// GetCtx sends a http GET to the bridge, aborting if ctx is cancelled
+// before the request completes.
+func (bridge *Bridge) GetCtx(ctx context.Context, path string) ([]byte, io.Reader, error) {
 	uri := bridge.uri(path)
 	log.Println("GET:", uri)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return []byte{}, nil, fmt.Errorf("unable to create GET request: %w", err)
+	}
+
 	client := bridge.newClient()
-	resp, err := client.Get(uri)
+	resp, err := client.Do(req)
 	if err != nil {
 		return []byte{}, nil, fmt.Errorf("unable to access bridge: %w", err)
 	}
@@ -78,6 +103,13 @@ func (bridge *Bridge) Get(path string) ([]byte, io.Reader, error) {
 // Put sends a http PUT to the bridge with
 // a body formatted with parameters (in a generic interface)
 func (bridge *Bridge) Put(path string, params interface{}) ([]byte, io.Reader, error) {
+	return bridge.PutCtx(context.Background(), path, params)
+}
+
+// PutCtx sends a http PUT to the bridge with a body formatted with
+// parameters (in a generic interface), aborting if ctx is cancelled
+// before the request completes.
+func (bridge *Bridge) PutCtx(ctx context.Context, path string, params interface{}) ([]byte, io.Reader, error) {
 	uri := bridge.uri(path)
 	log.Println("PUT:", uri)
 	data, err := json.Marshal(params)
@@ -85,7 +117,7 @@ func (bridge *Bridge) Put(path string, params interface{}) ([]byte, io.Reader, e
 		return []byte{}, nil, fmt.Errorf("unable to marshal PUT request interface: %w", err)
 	}
 
-	request, err := http.NewRequest("PUT", uri, bytes.NewReader(data))
+	request, err := http.NewRequestWithContext(ctx, "PUT", uri, bytes.NewReader(data))
 	if err != nil {
 		return []byte{}, nil, fmt.Errorf("unable to create PUT request: %w", err)
 	}
@@ -102,6 +134,14 @@ func (bridge *Bridge) Put(path string, params interface{}) ([]byte, io.Reader, e
 // a body formatted with parameters (in a generic interface).
 // If `params` is nil then it will send an empty body with the post request.
 func (bridge *Bridge) Post(path string, params interface{}) ([]byte, io.Reader, error) {
+	return bridge.PostCtx(context.Background(), path, params)
+}
+
+// PostCtx sends a http POST to the bridge with a body formatted with
+// parameters (in a generic interface), aborting if ctx is cancelled
+// before the request completes. If `params` is nil then it will send
+// an empty body with the post request.
+func (bridge *Bridge) PostCtx(ctx context.Context, path string, params interface{}) ([]byte, io.Reader, error) {
 	// Add the params to the request or allow an empty body
 	var request []byte
 	if params != nil {
@@ -115,8 +155,15 @@ func (bridge *Bridge) Post(path string, params interface{}) ([]byte, io.Reader,
 	// Send the request and handle the response
 	uri := bridge.uri(path)
 	log.Println("POST:", uri)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(request))
+	if err != nil {
+		return []byte{}, nil, fmt.Errorf("unable to create POST request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/json")
+
 	client := bridge.newClient()
-	resp, err := client.Post(uri, "text/json", bytes.NewReader(request))
+	resp, err := client.Do(req)
 	if err != nil {
 		return []byte{}, nil, fmt.Errorf("unable to access bridge: %w", err)
 	}
@@ -126,8 +173,14 @@ func (bridge *Bridge) Post(path string, params interface{}) ([]byte, io.Reader,
 
 // Delete sends a http DELETE to the bridge
 func (bridge *Bridge) Delete(path string) error {
+	return bridge.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx sends a http DELETE to the bridge, aborting if ctx is
+// cancelled before the request completes.
+func (bridge *Bridge) DeleteCtx(ctx context.Context, path string) error {
 	uri := bridge.uri(path)
-	req, err := http.NewRequest("DELETE", uri, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", uri, nil)
 	if err != nil {
 		return fmt.Errorf("unable to create DELETE request: %w", err)
 	}
@@ -146,6 +199,35 @@ func (bridge *Bridge) Delete(path string) error {
 	return nil
 }
 
+// ApplyLightStates PUTs each light's new state to the bridge concurrently,
+// fanning the requests out across at most Bridge.Concurrency workers (or
+// defaultApplyConcurrency if unset). It stops launching new requests as
+// soon as ctx is cancelled or one of the PUTs fails, and returns the first
+// error encountered.
+func (bridge *Bridge) ApplyLightStates(ctx context.Context, states map[int]LightState) error {
+	limit := bridge.Concurrency
+	if limit <= 0 {
+		limit = defaultApplyConcurrency
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(limit)
+
+	for index, state := range states {
+		index, state := index, state
+		group.Go(func() error {
+			uri := fmt.Sprintf("/api/%s/lights/%d/state", bridge.Username, index)
+			_, _, err := bridge.PutCtx(ctx, uri, state)
+			if err != nil {
+				return fmt.Errorf("unable to apply state to light %d: %w", index, err)
+			}
+			return nil
+		})
+	}
+
+	return group.Wait()
+}
+
 // HandleResponse manages the http.Response content from a
 // bridge Get/Put/Post/Delete by checking it for errors
 // and invalid return types.
@@ -160,34 +242,20 @@ func HandleResponse(resp *http.Response) ([]byte, io.Reader, error) {
 	defer resp.Body.Close()
 
 	reader := bytes.NewReader(body)
-	if strings.Contains(string(body), "\"error\"") {
-		errString := string(body)
-		errNum := errString[strings.Index(errString, "type\":")+6 : strings.Index(errString, ",\"address")]
-		errDesc := errString[strings.Index(errString, "description\":\"")+14 : strings.Index(errString, "\"}}")]
-		return []byte{}, nil, fmt.Errorf("failed to handle response: error type %s: %s", errNum, errDesc)
-	}
-
-	return body, reader, nil
-}
 
-// FindBridges will visit www.meethue.com/api/nupnp to see a list of
-// bridges on the local network.
-func FindBridges() ([]Bridge, error) {
-	bridge := Bridge{IPAddress: "www.meethue.com"}
-	body, _, err := bridge.Get("/api/nupnp")
-	if err != nil {
-		return []Bridge{}, fmt.Errorf("unable to locate bridge: %w", err)
+	// The bridge reports state-change results as an array of per-attribute
+	// results, so a single batched request can partially fail. If decoding
+	// into that shape fails, the body isn't in the APIResponse format (for
+	// example a plain "not available" string), so fall through and hand
+	// the raw body back to the caller.
+	var apiResp APIResponse
+	if err := json.Unmarshal(body, &apiResp); err == nil {
+		if errs := errorsFromResponse(apiResp); len(errs) > 0 {
+			return []byte{}, nil, fmt.Errorf("failed to handle response: %w", errs)
+		}
 	}
 
-	var bridges []Bridge
-	err = json.Unmarshal(body, &bridges)
-	if err != nil {
-		return bridges, fmt.Errorf("unable to unmarshal bridge list: %w", err)
-	}
-	if len(bridges) == 0 {
-		return bridges, errors.New("no bridges found")
-	}
-	return bridges, nil
+	return body, reader, nil
 }
 
 // NewBridge defines hardware that is compatible with Hue.
@@ -255,10 +323,26 @@ func (bridge *Bridge) CreateUser(deviceType string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	content := string(body)
-	username := content[strings.LastIndex(content, ":\"")+2 : strings.LastIndex(content, "\"")]
-	bridge.Username = username
-	return username, nil
+
+	var apiResp APIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return "", fmt.Errorf("unable to unmarshal create user response: %w", err)
+	}
+
+	for _, result := range apiResp {
+		raw, ok := result.Success["username"]
+		if !ok {
+			continue
+		}
+		var username string
+		if err := json.Unmarshal(raw, &username); err != nil {
+			return "", fmt.Errorf("unable to unmarshal username: %w", err)
+		}
+		bridge.Username = username
+		return username, nil
+	}
+
+	return "", fmt.Errorf("create user response did not contain a username")
 }
 
 // DeleteUser deletes a user given its USER KEY, not the string name.
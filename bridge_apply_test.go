@@ -0,0 +1,109 @@
+/*
+* bridge_apply_test.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBridge(t *testing.T, server *httptest.Server) Bridge {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unable to parse test server URL: %v", err)
+	}
+	return Bridge{IPAddress: u.Host, Username: "test-user"}
+}
+
+// TestApplyLightStatesRespectsConcurrencyCap drives ApplyLightStates
+// against enough lights that, left unbounded, every PUT would be
+// in flight at once, and asserts the observed concurrency never exceeds
+// Bridge.Concurrency.
+func TestApplyLightStatesRespectsConcurrencyCap(t *testing.T) {
+	const cap = 3
+	var current, peak int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		w.Write([]byte(`[{"success":{"/state":true}}]`))
+	}))
+	defer server.Close()
+
+	bridge := newTestBridge(t, server)
+	bridge.Concurrency = cap
+
+	states := map[int]LightState{}
+	for i := 0; i < 8; i++ {
+		states[i] = LightState{}
+	}
+
+	err := bridge.ApplyLightStates(context.Background(), states)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, int(atomic.LoadInt32(&peak)), cap)
+}
+
+// TestApplyLightStatesReturnsFirstError asserts that a single light
+// failing surfaces as an APIError ApplyLightStates' caller can
+// errors.As on, and that the group cancels its shared context as soon
+// as that failure happens, short-circuiting requests that haven't
+// started yet instead of firing all of them regardless.
+func TestApplyLightStatesReturnsFirstError(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if n == 1 {
+			w.Write([]byte(`[{"error":{"type":201,"address":"/lights/1/state","description":"parameter not available"}}]`))
+			return
+		}
+		// Any request other than the first one only reaches the server if
+		// ApplyLightStates failed to cancel the rest of the batch.
+		time.Sleep(time.Second)
+		w.Write([]byte(`[{"success":{"/state":true}}]`))
+	}))
+	defer server.Close()
+
+	bridge := newTestBridge(t, server)
+	bridge.Concurrency = 1 // serialize so the first request is guaranteed to fail before any other starts
+
+	states := map[int]LightState{}
+	for i := 0; i < 5; i++ {
+		states[i] = LightState{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := bridge.ApplyLightStates(ctx, states)
+	assert.Error(t, err)
+
+	var apiErrs APIErrors
+	assert.True(t, errors.As(err, &apiErrs))
+	assert.Len(t, apiErrs, 1)
+	assert.Equal(t, "parameter not available", apiErrs[0].Description)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests),
+		"cancelling the shared context should stop the remaining requests before they reach the server")
+}
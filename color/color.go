@@ -0,0 +1,232 @@
+/*
+* color.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+// Package color converts between RGB and the CIE xy color space that the
+// Hue bridge actually drives lights in, clamping into the correct color
+// gamut triangle for a given light model. Driving hue/sat directly works
+// for genuine Philips bulbs, but third-party Zigbee lights (IKEA TradFri,
+// innr, etc.) need xy+bri to reproduce colors correctly.
+package color
+
+import "math"
+
+// RGB is a color in the standard 0.0-1.0 sRGB color space.
+type RGB struct {
+	R, G, B float64
+}
+
+// XY is a point in the CIE 1931 color space, as understood by the Hue
+// bridge's `xy` light state attribute.
+type XY struct {
+	X, Y float32
+}
+
+// gamut is the triangle of xy points a light model can physically produce.
+type gamut struct {
+	Red, Green, Blue XY
+}
+
+// Gamut A, B, and C as published in the Philips Hue developer docs. Gamut A
+// covers the first generation Hue bulbs (e.g. LST001), Gamut B covers the
+// original LCT001-003 bulbs, and Gamut C covers newer Hue products.
+var (
+	gamutA = gamut{
+		Red:   XY{0.704, 0.296},
+		Green: XY{0.2151, 0.7106},
+		Blue:  XY{0.138, 0.080},
+	}
+	gamutB = gamut{
+		Red:   XY{0.675, 0.322},
+		Green: XY{0.409, 0.518},
+		Blue:  XY{0.167, 0.040},
+	}
+	gamutC = gamut{
+		Red:   XY{0.692, 0.308},
+		Green: XY{0.17, 0.7},
+		Blue:  XY{0.153, 0.048},
+	}
+)
+
+// gamutAModels and gamutBModels list the modelid values that fall outside
+// of the default Gamut C. Any modelid not listed here (including newer
+// bulbs like LCT010/LCT014) is assumed to be Gamut C.
+var gamutAModels = map[string]bool{
+	"LST001": true,
+}
+
+var gamutBModels = map[string]bool{
+	"LCT001": true,
+	"LCT002": true,
+	"LCT003": true,
+}
+
+func gamutForModel(modelID string) gamut {
+	if gamutAModels[modelID] {
+		return gamutA
+	}
+	if gamutBModels[modelID] {
+		return gamutB
+	}
+	return gamutC
+}
+
+// RGBToXY converts an sRGB color into the xy point the bridge should be
+// told to drive modelID at, clamping into that model's gamut triangle if
+// the requested color falls outside of what it can reproduce.
+func RGBToXY(rgb RGB, modelID string) XY {
+	r := gammaCorrect(rgb.R)
+	g := gammaCorrect(rgb.G)
+	b := gammaCorrect(rgb.B)
+
+	x := r*0.664511 + g*0.154324 + b*0.162028
+	y := r*0.283881 + g*0.668433 + b*0.047685
+	z := r*0.000088 + g*0.072310 + b*0.986039
+
+	sum := x + y + z
+	if sum == 0 {
+		return XY{0, 0}
+	}
+
+	point := XY{float32(x / sum), float32(y / sum)}
+	return clampToGamut(point, gamutForModel(modelID))
+}
+
+// XYToRGB converts an xy point, as reported in a light's current state,
+// back into an approximate sRGB color at full brightness, clamping into
+// modelID's gamut first in case the reported point sits fractionally
+// outside of it (bridge rounding, a light reporting a foreign gamut's
+// point, etc).
+func XYToRGB(xy XY, modelID string) RGB {
+	xy = clampToGamut(xy, gamutForModel(modelID))
+
+	x := float64(xy.X)
+	y := float64(xy.Y)
+	if y == 0 {
+		return RGB{}
+	}
+
+	z := 1 - x - y
+	Y := 1.0
+	X := (Y / y) * x
+	Z := (Y / y) * z
+
+	r := X*1.656492 - Y*0.354851 - Z*0.255038
+	g := -X*0.707196 + Y*1.655397 + Z*0.036152
+	b := X*0.051713 - Y*0.121364 + Z*1.011530
+
+	return RGB{
+		R: reverseGammaCorrect(r),
+		G: reverseGammaCorrect(g),
+		B: reverseGammaCorrect(b),
+	}
+}
+
+func gammaCorrect(c float64) float64 {
+	if c > 0.04045 {
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return c / 12.92
+}
+
+func reverseGammaCorrect(c float64) float64 {
+	if c <= 0.0031308 {
+		c = 12.92 * c
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	if c < 0 {
+		return 0
+	}
+	if c > 1 {
+		return 1
+	}
+	return c
+}
+
+// clampToGamut projects point onto the nearest point within g's triangle,
+// returning it unchanged if it already falls inside.
+func clampToGamut(point XY, g gamut) XY {
+	if inTriangle(point, g.Red, g.Green, g.Blue) {
+		return point
+	}
+
+	pRG := closestPointOnLine(g.Red, g.Green, point)
+	pGB := closestPointOnLine(g.Green, g.Blue, point)
+	pBR := closestPointOnLine(g.Blue, g.Red, point)
+
+	dRG := distance(point, pRG)
+	dGB := distance(point, pGB)
+	dBR := distance(point, pBR)
+
+	closest := pRG
+	min := dRG
+	if dGB < min {
+		closest = pGB
+		min = dGB
+	}
+	if dBR < min {
+		closest = pBR
+	}
+	return closest
+}
+
+func sign(p1, p2, p3 XY) float32 {
+	return (p1.X-p3.X)*(p2.Y-p3.Y) - (p2.X-p3.X)*(p1.Y-p3.Y)
+}
+
+func inTriangle(p, a, b, c XY) bool {
+	d1 := sign(p, a, b)
+	d2 := sign(p, b, c)
+	d3 := sign(p, c, a)
+
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+
+	return !(hasNeg && hasPos)
+}
+
+func closestPointOnLine(a, b, p XY) XY {
+	ap := XY{p.X - a.X, p.Y - a.Y}
+	ab := XY{b.X - a.X, b.Y - a.Y}
+
+	abLenSq := ab.X*ab.X + ab.Y*ab.Y
+	if abLenSq == 0 {
+		return a
+	}
+
+	t := (ap.X*ab.X + ap.Y*ab.Y) / abLenSq
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+
+	return XY{a.X + ab.X*t, a.Y + ab.Y*t}
+}
+
+func distance(a, b XY) float32 {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx + dy*dy
+}
+
+// Kelvin converts a color temperature in degrees Kelvin into the mired
+// value the bridge's `ct` light state attribute expects, clamped to the
+// 153-500 range (roughly 6500K-2000K) that Hue lights support.
+func Kelvin(k int) uint16 {
+	if k <= 0 {
+		k = 1
+	}
+	mired := 1000000 / k
+	if mired < 153 {
+		mired = 153
+	}
+	if mired > 500 {
+		mired = 500
+	}
+	return uint16(mired)
+}
@@ -0,0 +1,132 @@
+/*
+* color_test.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package color
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// inTriangleWithTolerance treats a point that lands within epsilon of an
+// edge as inside, since gamma-corrected conversions of fully-saturated
+// primaries land almost exactly on a gamut's vertices, where float32
+// rounding can otherwise tip the sign-based inTriangle check outside.
+func inTriangleWithTolerance(p, a, b, c XY, epsilon float32) bool {
+	d1 := sign(p, a, b)
+	d2 := sign(p, b, c)
+	d3 := sign(p, c, a)
+
+	hasNeg := d1 < -epsilon || d2 < -epsilon || d3 < -epsilon
+	hasPos := d1 > epsilon || d2 > epsilon || d3 > epsilon
+
+	return !(hasNeg && hasPos)
+}
+
+func TestRGBToXYStaysInGamut(t *testing.T) {
+	tests := []struct {
+		name    string
+		rgb     RGB
+		modelID string
+	}{
+		{"warm red on gamut B", RGB{0.9, 0.3, 0.2}, "LCT001"},
+		{"mixed green on gamut A", RGB{0.2, 0.8, 0.3}, "LST001"},
+		{"mixed blue on gamut C", RGB{0.1, 0.2, 0.9}, "LCT010"},
+		{"white on unknown model falls back to gamut C", RGB{1, 1, 1}, "UNKNOWN001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xy := RGBToXY(tt.rgb, tt.modelID)
+			g := gamutForModel(tt.modelID)
+			assert.True(t, inTriangleWithTolerance(xy, g.Red, g.Green, g.Blue, 1e-4),
+				"xy %+v should fall inside the gamut triangle for %s", xy, tt.modelID)
+		})
+	}
+}
+
+func TestClampToGamutLeavesInGamutPointsUnchanged(t *testing.T) {
+	g := gamutC
+	// The triangle's centroid is always inside it.
+	point := XY{
+		X: (g.Red.X + g.Green.X + g.Blue.X) / 3,
+		Y: (g.Red.Y + g.Green.Y + g.Blue.Y) / 3,
+	}
+
+	assert.Equal(t, point, clampToGamut(point, g))
+}
+
+func TestClampToGamutProjectsOutOfGamutPoints(t *testing.T) {
+	g := gamutC
+	outside := XY{X: 10, Y: 10}
+
+	clamped := clampToGamut(outside, g)
+
+	assert.True(t, inTriangle(clamped, g.Red, g.Green, g.Blue))
+	assert.NotEqual(t, outside, clamped)
+}
+
+func TestGamutForModel(t *testing.T) {
+	assert.Equal(t, gamutA, gamutForModel("LST001"))
+	assert.Equal(t, gamutB, gamutForModel("LCT001"))
+	assert.Equal(t, gamutC, gamutForModel("LCT010"))
+	assert.Equal(t, gamutC, gamutForModel("LCT014"))
+	assert.Equal(t, gamutC, gamutForModel("totally-unknown-model"))
+}
+
+func TestKelvin(t *testing.T) {
+	tests := []struct {
+		name string
+		k    int
+		want uint16
+	}{
+		{"mid-range converts to mired value", 4000, 250},
+		{"very high kelvin clamps to the 153 mired floor", 10000, 153},
+		{"very low kelvin clamps to the 500 mired ceiling", 1000, 500},
+		{"zero kelvin doesn't divide by zero", 0, 500},
+		{"negative kelvin doesn't divide by zero", -100, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Kelvin(tt.k)
+			assert.Equal(t, tt.want, got)
+			assert.GreaterOrEqual(t, got, uint16(153))
+			assert.LessOrEqual(t, got, uint16(500))
+		})
+	}
+}
+
+func TestXYToRGBRoundTrip(t *testing.T) {
+	original := RGB{R: 0.8, G: 0.2, B: 0.1}
+	xy := RGBToXY(original, "LCT010")
+	rgb := XYToRGB(xy, "LCT010")
+
+	// The conversion is lossy (gamut clamping, gamma correction), so just
+	// assert it lands in the valid 0-1 range rather than exact equality.
+	assert.GreaterOrEqual(t, rgb.R, 0.0)
+	assert.LessOrEqual(t, rgb.R, 1.0)
+	assert.GreaterOrEqual(t, rgb.G, 0.0)
+	assert.LessOrEqual(t, rgb.G, 1.0)
+	assert.GreaterOrEqual(t, rgb.B, 0.0)
+	assert.LessOrEqual(t, rgb.B, 1.0)
+}
+
+func TestXYToRGBClampsToModelGamut(t *testing.T) {
+	// A point near gamut C's blue primary sits outside gamut A, so
+	// XYToRGB should clamp it before converting rather than using it
+	// as-is.
+	outside := gamutC.Blue
+	clamped := clampToGamut(outside, gamutA)
+
+	withClamp := XYToRGB(outside, "LST001")
+	withoutClamp := XYToRGB(clamped, "LST001")
+
+	assert.Equal(t, withoutClamp, withClamp)
+	assert.NotEqual(t, outside, clamped, "fixture point should actually be outside gamut A")
+}
@@ -0,0 +1,462 @@
+/*
+* discovery.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddress = "239.255.255.250:1900"
+	ssdpSearch  = "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 3\r\n" +
+		"ST: urn:schemas-upnp-org:device:basic:1\r\n\r\n"
+
+	mdnsPort   = 5353
+	hueService = "_hue._tcp.local."
+
+	// resolveTimeout bounds how long resolving already-collected SSDP
+	// locations (description.xml GETs) is allowed to take once the
+	// caller's ctx has already expired.
+	resolveTimeout = 5 * time.Second
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+
+// FindBridges locates bridges reachable from this host. It first tries
+// Signify's nupnp endpoint and, if that fails or returns no bridges (a
+// real recurring problem on isolated LANs or when meethue.com is down),
+// falls back to local SSDP discovery.
+func FindBridges() ([]Bridge, error) {
+	bridges, err := findBridgesNupnp()
+	if err == nil && len(bridges) > 0 {
+		return bridges, nil
+	}
+
+	local, localErr := FindBridgesLocal(context.Background(), 5*time.Second)
+	if localErr != nil || len(local) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return bridges, err
+	}
+	return local, nil
+}
+
+func findBridgesNupnp() ([]Bridge, error) {
+	bridge := Bridge{IPAddress: "www.meethue.com"}
+	body, _, err := bridge.Get("/api/nupnp")
+	if err != nil {
+		return []Bridge{}, fmt.Errorf("unable to locate bridge: %w", err)
+	}
+
+	var bridges []Bridge
+	err = json.Unmarshal(body, &bridges)
+	if err != nil {
+		return bridges, fmt.Errorf("unable to unmarshal bridge list: %w", err)
+	}
+	if len(bridges) == 0 {
+		return bridges, fmt.Errorf("no bridges found")
+	}
+	return bridges, nil
+}
+
+// FindBridgesLocal discovers bridges on the local network via SSDP,
+// multicasting M-SEARCH to 239.255.255.250:1900 and collecting responses
+// for timeout. Each distinct LOCATION it hears back is fetched and
+// filtered down to genuine Signify Hue bridges.
+func FindBridgesLocal(ctx context.Context, timeout time.Duration) ([]Bridge, error) {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve ssdp multicast address: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open udp socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo([]byte(ssdpSearch), addr); err != nil {
+		return nil, fmt.Errorf("unable to send ssdp search: %w", err)
+	}
+
+	locations := map[string]bool{}
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 2048)
+
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is already cancelled/expired here, so resolving against
+			// it would fail every fetchDescription call outright and drop
+			// every location already collected. Give the already-known
+			// locations a fresh, short-lived context to resolve against
+			// instead.
+			resolveCtx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+			defer cancel()
+			return resolveSSDPBridges(resolveCtx, locations), ctx.Err()
+		default:
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(remaining))
+
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+
+		if loc := ssdpLocation(string(buf[:n])); loc != "" {
+			locations[loc] = true
+		}
+	}
+
+	return resolveSSDPBridges(ctx, locations), nil
+}
+
+// ssdpLocation extracts the LOCATION header from a raw SSDP response.
+func ssdpLocation(response string) string {
+	for _, line := range strings.Split(response, "\r\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(parts[0]), "LOCATION") {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+	return ""
+}
+
+// resolveSSDPBridges fetches description.xml for each discovered location
+// and keeps only the ones that identify as genuine Signify Hue bridges.
+func resolveSSDPBridges(ctx context.Context, locations map[string]bool) []Bridge {
+	var bridges []Bridge
+	for location := range locations {
+		info, host, err := fetchDescription(ctx, location)
+		if err != nil {
+			continue
+		}
+		if !isHueBridge(info) {
+			continue
+		}
+		bridges = append(bridges, Bridge{IPAddress: host, Info: info})
+	}
+	return bridges
+}
+
+func fetchDescription(ctx context.Context, location string) (BridgeInfo, string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return BridgeInfo{}, "", fmt.Errorf("unable to parse description location: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return BridgeInfo{}, "", fmt.Errorf("unable to create description request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return BridgeInfo{}, "", fmt.Errorf("unable to fetch description: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var info BridgeInfo
+	if err := xml.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return BridgeInfo{}, "", fmt.Errorf("unable to decode description: %w", err)
+	}
+
+	return info, u.Hostname(), nil
+}
+
+func isHueBridge(info BridgeInfo) bool {
+	return info.Device.Manufacturer == "Signify" &&
+		strings.Contains(info.Device.ModelName, "Philips hue bridge")
+}
+
+// FindBridgesMDNS discovers bridges on the local network by querying
+// `_hue._tcp.local.` over mDNS (224.0.0.251:5353) and resolving the first
+// matching PTR answer's target in each response back to a host via its
+// SRV/A glue records, the same way FindBridgesLocal does for SSDP
+// responses.
+func FindBridgesMDNS(ctx context.Context) ([]Bridge, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("unable to join mdns multicast group: %w", err)
+	}
+	defer conn.Close()
+
+	query := buildMDNSQuery(hueService)
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("unable to send mdns query: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(3 * time.Second)
+	}
+
+	hosts := map[string]bool{}
+	buf := make([]byte, 2048)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(remaining))
+
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+
+		host, ok := hueHostFromMDNSResponse(buf[:n])
+		if ok {
+			hosts[host] = true
+		}
+	}
+
+	var bridges []Bridge
+	for host := range hosts {
+		bridge := Bridge{IPAddress: host}
+		if err := bridge.GetInfo(); err != nil {
+			continue
+		}
+		if isHueBridge(bridge.Info) {
+			bridges = append(bridges, bridge)
+		}
+	}
+	return bridges, nil
+}
+
+// buildMDNSQuery constructs the smallest possible DNS query packet asking
+// for PTR records for name, as sent over mDNS multicast.
+func buildMDNSQuery(name string) []byte {
+	packet := []byte{
+		0x00, 0x00, // transaction ID (unused for mDNS)
+		0x00, 0x00, // flags: standard query
+		0x00, 0x01, // questions: 1
+		0x00, 0x00, // answer RRs
+		0x00, 0x00, // authority RRs
+		0x00, 0x00, // additional RRs
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		packet = append(packet, byte(len(label)))
+		packet = append(packet, []byte(label)...)
+	}
+	packet = append(packet, 0x00)  // root label
+	packet = append(packet, 0, 12) // QTYPE: PTR
+	packet = append(packet, 0, 1)  // QCLASS: IN
+	return packet
+}
+
+// dnsRecord is a single resource record parsed out of a DNS/mDNS message.
+// Data* point back into the original message buffer rather than copying,
+// since PTR/SRV record contents can themselves contain compressed name
+// pointers that are only resolvable relative to the full message.
+type dnsRecord struct {
+	Name             string
+	Type             uint16
+	DataOffset, Data int
+}
+
+// hueHostFromMDNSResponse decodes an mDNS response packet and, if it
+// answers a `_hue._tcp.local.` PTR query with SRV/A glue for the target
+// service instance, returns that instance's IP address.
+func hueHostFromMDNSResponse(buf []byte) (string, bool) {
+	records, err := parseDNSMessage(buf)
+	if err != nil {
+		return "", false
+	}
+
+	var serviceName string
+	for _, rr := range records {
+		if rr.Type != dnsTypePTR || !dnsNameEqual(rr.Name, hueService) {
+			continue
+		}
+		target, _, err := readDNSName(buf, rr.DataOffset)
+		if err != nil {
+			continue
+		}
+		serviceName = target
+		break
+	}
+	if serviceName == "" {
+		return "", false
+	}
+
+	var hostName string
+	for _, rr := range records {
+		if rr.Type != dnsTypeSRV || !dnsNameEqual(rr.Name, serviceName) {
+			continue
+		}
+		if rr.DataOffset+6 > len(buf) {
+			continue
+		}
+		target, _, err := readDNSName(buf, rr.DataOffset+6)
+		if err != nil {
+			continue
+		}
+		hostName = target
+		break
+	}
+	if hostName == "" {
+		return "", false
+	}
+
+	for _, rr := range records {
+		if rr.Type != dnsTypeA || !dnsNameEqual(rr.Name, hostName) {
+			continue
+		}
+		if rr.DataOffset+4 > len(buf) {
+			continue
+		}
+		return net.IP(buf[rr.DataOffset : rr.DataOffset+4]).String(), true
+	}
+
+	return "", false
+}
+
+func dnsNameEqual(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+const (
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeSRV = 33
+)
+
+// parseDNSMessage decodes the header, question, answer, authority, and
+// additional sections of a DNS message, returning every resource record
+// found in the answer/authority/additional sections (the question section
+// carries no usable data beyond its name, which callers don't need here).
+func parseDNSMessage(buf []byte) ([]dnsRecord, error) {
+	if len(buf) < 12 {
+		return nil, fmt.Errorf("dns message too short")
+	}
+
+	qdCount := binary.BigEndian.Uint16(buf[4:6])
+	anCount := binary.BigEndian.Uint16(buf[6:8])
+	nsCount := binary.BigEndian.Uint16(buf[8:10])
+	arCount := binary.BigEndian.Uint16(buf[10:12])
+
+	offset := 12
+	for i := 0; i < int(qdCount); i++ {
+		_, next, err := readDNSName(buf, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4 // QTYPE + QCLASS
+	}
+
+	var records []dnsRecord
+	for _, count := range []int{int(anCount), int(nsCount), int(arCount)} {
+		var section []dnsRecord
+		var err error
+		section, offset, err = readDNSRecords(buf, offset, count)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, section...)
+	}
+
+	return records, nil
+}
+
+// readDNSRecords parses count resource records starting at offset,
+// returning them along with the offset just past the last one.
+func readDNSRecords(buf []byte, offset, count int) ([]dnsRecord, int, error) {
+	records := make([]dnsRecord, 0, count)
+	for i := 0; i < count; i++ {
+		name, next, err := readDNSName(buf, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = next
+
+		if offset+10 > len(buf) {
+			return nil, 0, fmt.Errorf("dns record header out of bounds")
+		}
+		rtype := binary.BigEndian.Uint16(buf[offset:])
+		rdlength := int(binary.BigEndian.Uint16(buf[offset+8:]))
+		offset += 10
+
+		if offset+rdlength > len(buf) {
+			return nil, 0, fmt.Errorf("dns record data out of bounds")
+		}
+		records = append(records, dnsRecord{Name: name, Type: rtype, DataOffset: offset, Data: rdlength})
+		offset += rdlength
+	}
+	return records, offset, nil
+}
+
+// readDNSName decodes a (possibly compressed) DNS name starting at
+// offset, returning the dotted name and the offset immediately following
+// it in the original, uncompressed sense (i.e. after following any
+// pointer, the position to resume reading from is the one right after the
+// pointer itself, not the jump target).
+func readDNSName(buf []byte, offset int) (string, int, error) {
+	var labels []string
+	pos := offset
+	next := -1
+
+	for i := 0; i < 128; i++ {
+		if pos >= len(buf) {
+			return "", 0, fmt.Errorf("dns name out of bounds")
+		}
+		length := int(buf[pos])
+
+		if length == 0 {
+			pos++
+			if next == -1 {
+				next = pos
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		}
+
+		if length&0xC0 == 0xC0 {
+			if pos+1 >= len(buf) {
+				return "", 0, fmt.Errorf("dns name pointer out of bounds")
+			}
+			pointer := int(length&0x3F)<<8 | int(buf[pos+1])
+			if next == -1 {
+				next = pos + 2
+			}
+			pos = pointer
+			continue
+		}
+
+		pos++
+		if pos+length > len(buf) {
+			return "", 0, fmt.Errorf("dns name label out of bounds")
+		}
+		labels = append(labels, string(buf[pos:pos+length]))
+		pos += length
+	}
+
+	return "", 0, fmt.Errorf("dns name compression loop")
+}
@@ -0,0 +1,212 @@
+/*
+* discovery_test.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// httpHandlerFunc serves body with a 200 status for every request, used
+// to stand in for a bridge's description.xml endpoint in tests.
+func httpHandlerFunc(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
+
+const hueDescriptionXML = `<?xml version="1.0" encoding="UTF-8" ?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<device>
+<manufacturer>Signify</manufacturer>
+<modelName>Philips hue bridge 2015</modelName>
+</device>
+</root>`
+
+func TestResolveSSDPBridgesFindsHueBridge(t *testing.T) {
+	srv := httptest.NewServer(httpHandlerFunc(hueDescriptionXML))
+	defer srv.Close()
+
+	locations := map[string]bool{srv.URL + "/description.xml": true}
+	bridges := resolveSSDPBridges(context.Background(), locations)
+
+	assert.Len(t, bridges, 1)
+}
+
+// TestResolveSSDPBridgesWithExpiredContextFindsNothing demonstrates the
+// bug FindBridgesLocal used to have: resolving with an already-cancelled
+// context drops every already-collected location, since every
+// fetchDescription call fails immediately with context.Canceled.
+func TestResolveSSDPBridgesWithExpiredContextFindsNothing(t *testing.T) {
+	srv := httptest.NewServer(httpHandlerFunc(hueDescriptionXML))
+	defer srv.Close()
+
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	locations := map[string]bool{srv.URL + "/description.xml": true}
+	bridges := resolveSSDPBridges(expired, locations)
+
+	assert.Empty(t, bridges, "an already-cancelled context can't resolve any location")
+}
+
+// TestResolveSSDPBridgesWithFreshContextAfterExpiry reproduces the step
+// FindBridgesLocal takes once its own ctx has expired: resolving the
+// collected locations against a fresh context bounded by resolveTimeout
+// still finds the bridge, unlike the expired context in
+// TestResolveSSDPBridgesWithExpiredContextFindsNothing above. It does not
+// call FindBridgesLocal itself.
+func TestResolveSSDPBridgesWithFreshContextAfterExpiry(t *testing.T) {
+	srv := httptest.NewServer(httpHandlerFunc(hueDescriptionXML))
+	defer srv.Close()
+
+	locations := map[string]bool{srv.URL + "/description.xml": true}
+
+	expired, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+
+	resolveCtx, cancelResolve := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancelResolve()
+
+	bridges := resolveSSDPBridges(resolveCtx, locations)
+	assert.Len(t, bridges, 1, "resolving against a fresh context should still find the bridge")
+}
+
+// buildHueMDNSResponse assembles a minimal mDNS response packet containing
+// a PTR record for _hue._tcp.local. pointing at a service instance, an SRV
+// record for that instance pointing at a hostname, and an A record
+// resolving that hostname to ip. Names are spelled out in full (no
+// compression) to keep the fixture easy to read.
+func buildHueMDNSResponse(t *testing.T, instance, host string, ip [4]byte) []byte {
+	t.Helper()
+
+	var buf []byte
+	appendName := func(name string) {
+		for _, label := range splitDNSName(name) {
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, []byte(label)...)
+		}
+		buf = append(buf, 0x00)
+	}
+
+	// header: 0 questions, 3 answers, 0 authority, 0 additional
+	buf = append(buf, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0)
+
+	// PTR _hue._tcp.local. -> instance
+	appendName(hueService)
+	buf = append(buf, 0, dnsTypePTR, 0, 1, 0, 0, 0, 0)
+	ptrStart := len(buf)
+	buf = append(buf, 0, 0) // rdlength placeholder
+	appendName(instance)
+	setUint16(buf, ptrStart, uint16(len(buf)-ptrStart-2))
+
+	// SRV instance -> host
+	appendName(instance)
+	buf = append(buf, 0, dnsTypeSRV, 0, 1, 0, 0, 0, 0)
+	srvStart := len(buf)
+	buf = append(buf, 0, 0)              // rdlength placeholder
+	buf = append(buf, 0, 0, 0, 0, 0, 80) // priority, weight, port
+	appendName(host)
+	setUint16(buf, srvStart, uint16(len(buf)-srvStart-2))
+
+	// A host -> ip
+	appendName(host)
+	buf = append(buf, 0, dnsTypeA, 0, 1, 0, 0, 0, 0, 0, 4)
+	buf = append(buf, ip[:]...)
+
+	return buf
+}
+
+func splitDNSName(name string) []string {
+	var labels []string
+	start := 0
+	trimmed := name
+	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == '.' {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	for i := 0; i <= len(trimmed); i++ {
+		if i == len(trimmed) || trimmed[i] == '.' {
+			labels = append(labels, trimmed[start:i])
+			start = i + 1
+		}
+	}
+	return labels
+}
+
+func setUint16(buf []byte, offset int, v uint16) {
+	buf[offset] = byte(v >> 8)
+	buf[offset+1] = byte(v)
+}
+
+func TestHueHostFromMDNSResponse(t *testing.T) {
+	packet := buildHueMDNSResponse(t, "bridge._hue._tcp.local.", "bridge.local.", [4]byte{192, 168, 1, 50})
+
+	host, ok := hueHostFromMDNSResponse(packet)
+	assert.True(t, ok)
+	assert.Equal(t, "192.168.1.50", host)
+}
+
+func TestHueHostFromMDNSResponseIgnoresUnrelatedService(t *testing.T) {
+	packet := buildHueMDNSResponse(t, "printer._ipp._tcp.local.", "printer.local.", [4]byte{192, 168, 1, 51})
+	// Overwrite the PTR record's name so it answers a different service.
+	packet = replaceDNSName(packet, hueService, "_ipp._tcp.local.")
+
+	_, ok := hueHostFromMDNSResponse(packet)
+	assert.False(t, ok)
+}
+
+// replaceDNSName swaps the first occurrence of a wire-format encoding of
+// from with the wire-format encoding of to, for test fixtures only; it
+// requires the replacement to encode to the same number of bytes.
+func replaceDNSName(buf []byte, from, to string) []byte {
+	encode := func(name string) []byte {
+		var out []byte
+		for _, label := range splitDNSName(name) {
+			out = append(out, byte(len(label)))
+			out = append(out, []byte(label)...)
+		}
+		return append(out, 0x00)
+	}
+
+	fromBytes := encode(from)
+	toBytes := encode(to)
+	if len(fromBytes) != len(toBytes) {
+		return buf
+	}
+
+	for i := 0; i+len(fromBytes) <= len(buf); i++ {
+		match := true
+		for j := range fromBytes {
+			if buf[i+j] != fromBytes[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			copy(buf[i:i+len(toBytes)], toBytes)
+			break
+		}
+	}
+	return buf
+}
+
+func TestReadDNSNameWithCompression(t *testing.T) {
+	// "local." at offset 0, then a label "bridge" pointing back at it.
+	buf := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 6, 'b', 'r', 'i', 'd', 'g', 'e', 0xC0, 0x00}
+
+	name, next, err := readDNSName(buf, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "bridge.local.", name)
+	assert.Equal(t, len(buf), next)
+}
@@ -0,0 +1,60 @@
+/*
+* light.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"fmt"
+
+	"github.com/zegl/gohue/color"
+)
+
+// Light struct defines all the parameters present in a Philips Hue light.
+// Bridge.GetAllLights, Bridge.GetLightByIndex, and Bridge.GetLightByName
+// populate Index and Bridge so that the returned value can issue its own
+// state changes.
+type Light struct {
+	State struct {
+		On        bool      `json:"on"`
+		Bri       uint8     `json:"bri"`
+		Hue       uint16    `json:"hue"`
+		Sat       uint8     `json:"sat"`
+		XY        []float32 `json:"xy"`
+		CT        uint16    `json:"ct"`
+		Alert     string    `json:"alert"`
+		Effect    string    `json:"effect"`
+		ColorMode string    `json:"colormode"`
+		Reachable bool      `json:"reachable"`
+	} `json:"state"`
+	Type             string `json:"type"`
+	Name             string `json:"name"`
+	ModelID          string `json:"modelid"`
+	ManufacturerName string `json:"manufacturername"`
+	UniqueID         string `json:"uniqueid"`
+	SWVersion        string `json:"swversion"`
+
+	Index  int
+	Bridge *Bridge
+}
+
+// SetColorXY drives the light via the `xy`+`bri` color space instead of
+// hue/sat. This is the color space third-party Zigbee bulbs (IKEA TradFri,
+// innr, etc.) need to be driven in to behave correctly; see hue/color for
+// converting RGB into a gamut-correct XY value for this light's ModelID.
+func (light *Light) SetColorXY(xy color.XY, bri uint8) error {
+	uri := fmt.Sprintf("/api/%s/lights/%d/state", light.Bridge.Username, light.Index)
+	params := map[string]interface{}{
+		"xy":  [2]float32{xy.X, xy.Y},
+		"bri": bri,
+		"on":  true,
+	}
+	_, _, err := light.Bridge.Put(uri, params)
+	if err != nil {
+		return fmt.Errorf("unable to set light %d color: %w", light.Index, err)
+	}
+	return nil
+}
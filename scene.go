@@ -0,0 +1,64 @@
+/*
+* scene.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Scene struct defines a Philips Hue scene: a named, saved collection of
+// light states. Bridge.GetAllScenes populates ID and Bridge so a scene can
+// be identified and inspected further.
+type Scene struct {
+	Name        string   `json:"name"`
+	Lights      []string `json:"lights"`
+	Owner       string   `json:"owner,omitempty"`
+	Recycle     bool     `json:"recycle,omitempty"`
+	Locked      bool     `json:"locked,omitempty"`
+	Picture     string   `json:"picture,omitempty"`
+	LastUpdated string   `json:"lastupdated,omitempty"`
+	Version     int      `json:"version,omitempty"`
+
+	ID     string
+	Bridge *Bridge
+}
+
+// GetAllScenes retrieves every scene stored on the bridge.
+func (bridge *Bridge) GetAllScenes() ([]Scene, error) {
+	uri := fmt.Sprintf("/api/%s/scenes", bridge.Username)
+	body, _, err := bridge.Get(uri)
+	if err != nil {
+		return []Scene{}, err
+	}
+
+	// An id is at the top of every scene in the map, same as lights/sensors.
+	sceneMap := map[string]Scene{}
+	err = json.Unmarshal(body, &sceneMap)
+	if err != nil {
+		return []Scene{}, fmt.Errorf("unable to unmarshal GetAllScenes response: %w", err)
+	}
+
+	scenes := make([]Scene, 0, len(sceneMap))
+	for id, scene := range sceneMap {
+		scene.ID = id
+		scene.Bridge = bridge
+		scenes = append(scenes, scene)
+	}
+	return scenes, nil
+}
+
+// CreateScene saves a new scene on the bridge.
+func (bridge *Bridge) CreateScene(scene Scene) error {
+	uri := fmt.Sprintf("/api/%s/scenes", bridge.Username)
+	_, _, err := bridge.Post(uri, scene)
+	if err != nil {
+		return err
+	}
+	return nil
+}
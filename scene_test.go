@@ -8,8 +8,8 @@
 package hue
 
 import (
-	"testing"
 	"os"
+	"testing"
 )
 
 func TestGetAllScenes(t *testing.T) {
@@ -0,0 +1,33 @@
+/*
+* sensor.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+// Sensor struct defines all the parameters present in a Philips Hue
+// sensor, such as a dimmer switch or motion sensor. Bridge.GetAllSensors
+// and Bridge.GetSensorByIndex populate Index and Bridge so that the
+// returned value can be used to poll for further updates.
+type Sensor struct {
+	State struct {
+		ButtonEvent int    `json:"buttonevent"`
+		LastUpdated string `json:"lastupdated"`
+		Presence    bool   `json:"presence"`
+	} `json:"state"`
+	Config struct {
+		On        bool `json:"on"`
+		Reachable bool `json:"reachable"`
+		Battery   int  `json:"battery"`
+	} `json:"config"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	ModelID   string `json:"modelid"`
+	UniqueID  string `json:"uniqueid"`
+	SWVersion string `json:"swversion"`
+
+	Index  int
+	Bridge *Bridge
+}
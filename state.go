@@ -0,0 +1,24 @@
+/*
+* state.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+// LightState describes the desired state of a single light, formatted
+// exactly as the bridge's `/lights/<id>/state` endpoint expects it. Every
+// field is a pointer or carries `omitempty` so that only the attributes a
+// caller actually sets are sent in the PUT body.
+type LightState struct {
+	On             *bool     `json:"on,omitempty"`
+	Bri            *uint8    `json:"bri,omitempty"`
+	Hue            *uint16   `json:"hue,omitempty"`
+	Sat            *uint8    `json:"sat,omitempty"`
+	XY             []float32 `json:"xy,omitempty"`
+	CT             *uint16   `json:"ct,omitempty"`
+	Alert          string    `json:"alert,omitempty"`
+	Effect         string    `json:"effect,omitempty"`
+	TransitionTime *uint16   `json:"transitiontime,omitempty"`
+}
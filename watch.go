@@ -0,0 +1,246 @@
+/*
+* watch.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event is emitted on the channel returned by Bridge.Watch as it notices
+// changes on the bridge. It is implemented by LightStateChanged,
+// LightAdded, LightRemoved, SensorTriggered, and ReachabilityChanged.
+type Event interface {
+	isEvent()
+}
+
+// LightStateChanged is emitted when a light's state (on/off, brightness,
+// color, etc.) differs from what was last observed. Reachability changes
+// are reported separately via ReachabilityChanged.
+type LightStateChanged struct {
+	Index    int
+	Old, New Light
+}
+
+// LightAdded is emitted the first time a light is seen at Index.
+type LightAdded struct {
+	Index int
+	Light Light
+}
+
+// LightRemoved is emitted when a previously seen light disappears from
+// the bridge's light list.
+type LightRemoved struct {
+	Index int
+}
+
+// ReachabilityChanged is emitted when a light's State.Reachable flag
+// flips, independently of any other state change.
+type ReachabilityChanged struct {
+	Index     int
+	Reachable bool
+}
+
+// SensorTriggered is emitted when a sensor's state.lastupdated advances,
+// meaning it fired since it was last observed (a button press, a motion
+// event, etc).
+type SensorTriggered struct {
+	Index       int
+	ButtonEvent int
+}
+
+func (LightStateChanged) isEvent()   {}
+func (LightAdded) isEvent()          {}
+func (LightRemoved) isEvent()        {}
+func (ReachabilityChanged) isEvent() {}
+func (SensorTriggered) isEvent()     {}
+
+// defaultWatchInterval is the poll interval WatchSensors hands to Watch,
+// since its signature doesn't take one of its own.
+const defaultWatchInterval = 5 * time.Second
+
+// Watch polls the bridge's lights and sensors at interval, diffing each
+// snapshot against the last one and emitting Event values (including
+// SensorTriggered) on the returned channel as changes are noticed. The
+// channel is closed once ctx is cancelled.
+func (bridge *Bridge) Watch(ctx context.Context, interval time.Duration) (<-chan Event, error) {
+	lights, err := bridge.GetAllLights()
+	if err != nil {
+		return nil, fmt.Errorf("unable to take initial light snapshot: %w", err)
+	}
+	sensors, err := bridge.GetAllSensors()
+	if err != nil {
+		return nil, fmt.Errorf("unable to take initial sensor snapshot: %w", err)
+	}
+
+	events := make(chan Event)
+	lastLights := lightsByIndex(lights)
+	lastSensors := sensorLastUpdated(sensors)
+
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if current, err := bridge.GetAllLights(); err == nil {
+					currentByIndex := lightsByIndex(current)
+					if !diffLights(lastLights, currentByIndex, events, ctx) {
+						return
+					}
+					lastLights = currentByIndex
+				}
+				if current, err := bridge.GetAllSensors(); err == nil {
+					if !diffSensors(lastSensors, current, events, ctx) {
+						return
+					}
+					lastSensors = sensorLastUpdated(current)
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// WatchSensors is a convenience wrapper around Watch for callers who only
+// care about sensors (dimmer/motion switches, etc): it polls at
+// defaultWatchInterval and filters the stream down to SensorTriggered
+// events.
+func (bridge *Bridge) WatchSensors(ctx context.Context) (<-chan Event, error) {
+	all, err := bridge.Watch(ctx, defaultWatchInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for event := range all {
+			if _, ok := event.(SensorTriggered); !ok {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// sensorLastUpdated snapshots each sensor's state.lastupdated value, used
+// to detect when a sensor fires between polls.
+func sensorLastUpdated(sensors []Sensor) map[int]string {
+	m := make(map[int]string, len(sensors))
+	for _, sensor := range sensors {
+		m[sensor.Index] = sensor.State.LastUpdated
+	}
+	return m
+}
+
+// diffSensors emits a SensorTriggered event for every sensor whose
+// state.lastupdated advanced since old was taken, stopping early if ctx
+// is cancelled. It reports whether the caller should keep watching.
+func diffSensors(old map[int]string, current []Sensor, events chan<- Event, ctx context.Context) bool {
+	for _, sensor := range current {
+		if sensor.State.LastUpdated == old[sensor.Index] {
+			continue
+		}
+		select {
+		case events <- SensorTriggered{Index: sensor.Index, ButtonEvent: sensor.State.ButtonEvent}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// lightStateEqual reports whether a and b's light state is the same in
+// everything other than reachability, which is tracked separately via
+// ReachabilityChanged.
+func lightStateEqual(a, b Light) bool {
+	if a.State.On != b.State.On ||
+		a.State.Bri != b.State.Bri ||
+		a.State.Hue != b.State.Hue ||
+		a.State.Sat != b.State.Sat ||
+		a.State.CT != b.State.CT ||
+		a.State.Alert != b.State.Alert ||
+		a.State.Effect != b.State.Effect ||
+		a.State.ColorMode != b.State.ColorMode {
+		return false
+	}
+	if len(a.State.XY) != len(b.State.XY) {
+		return false
+	}
+	for i := range a.State.XY {
+		if a.State.XY[i] != b.State.XY[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lightsByIndex(lights []Light) map[int]Light {
+	m := make(map[int]Light, len(lights))
+	for _, light := range lights {
+		m[light.Index] = light
+	}
+	return m
+}
+
+// diffLights compares two light snapshots and sends the resulting events,
+// stopping early if ctx is cancelled. It reports whether the caller
+// should keep watching.
+func diffLights(old, current map[int]Light, events chan<- Event, ctx context.Context) bool {
+	send := func(e Event) bool {
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for index, light := range current {
+		previous, existed := old[index]
+		if !existed {
+			if !send(LightAdded{Index: index, Light: light}) {
+				return false
+			}
+			continue
+		}
+		if previous.State.Reachable != light.State.Reachable {
+			if !send(ReachabilityChanged{Index: index, Reachable: light.State.Reachable}) {
+				return false
+			}
+		}
+		if !lightStateEqual(previous, light) {
+			if !send(LightStateChanged{Index: index, Old: previous, New: light}) {
+				return false
+			}
+		}
+	}
+
+	for index := range old {
+		if _, stillPresent := current[index]; !stillPresent {
+			if !send(LightRemoved{Index: index}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,198 @@
+/*
+* watch_test.go
+* GoHue library for Philips Hue
+* Copyright (C) 2016 Collin Guarino (Collinux) collinux[-at-]users.noreply.github.com
+* License: GPL version 2 or higher http://www.gnu.org/licenses/gpl.html
+ */
+
+package hue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collectEvents drains events into a slice until the channel closes.
+func collectEvents(events <-chan Event) []Event {
+	var got []Event
+	for event := range events {
+		got = append(got, event)
+	}
+	return got
+}
+
+func TestDiffLights(t *testing.T) {
+	reachableOn := Light{Index: 1}
+	reachableOn.State.On = true
+	reachableOn.State.Reachable = true
+
+	reachableOff := reachableOn
+	reachableOff.State.On = false
+
+	unreachable := reachableOn
+	unreachable.State.Reachable = false
+
+	newLight := Light{Index: 2}
+
+	tests := []struct {
+		name string
+		old  map[int]Light
+		cur  map[int]Light
+		want []Event
+	}{
+		{
+			name: "no changes emits nothing",
+			old:  map[int]Light{1: reachableOn},
+			cur:  map[int]Light{1: reachableOn},
+			want: nil,
+		},
+		{
+			name: "new light emits LightAdded",
+			old:  map[int]Light{},
+			cur:  map[int]Light{2: newLight},
+			want: []Event{LightAdded{Index: 2, Light: newLight}},
+		},
+		{
+			name: "removed light emits LightRemoved",
+			old:  map[int]Light{1: reachableOn},
+			cur:  map[int]Light{},
+			want: []Event{LightRemoved{Index: 1}},
+		},
+		{
+			name: "state change emits LightStateChanged",
+			old:  map[int]Light{1: reachableOn},
+			cur:  map[int]Light{1: reachableOff},
+			want: []Event{LightStateChanged{Index: 1, Old: reachableOn, New: reachableOff}},
+		},
+		{
+			name: "reachability change emits ReachabilityChanged, not LightStateChanged",
+			old:  map[int]Light{1: reachableOn},
+			cur:  map[int]Light{1: unreachable},
+			want: []Event{ReachabilityChanged{Index: 1, Reachable: false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan Event, len(tt.want))
+			ok := diffLights(tt.old, tt.cur, events, context.Background())
+			close(events)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, collectEvents(events))
+		})
+	}
+}
+
+func TestDiffLightsStopsOnContextCancel(t *testing.T) {
+	old := map[int]Light{}
+	cur := map[int]Light{1: {Index: 1}, 2: {Index: 2}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// An unbuffered channel with nothing reading from it means diffLights
+	// can't send either LightAdded event, so it must notice ctx is already
+	// done instead of blocking forever.
+	events := make(chan Event)
+	ok := diffLights(old, cur, events, ctx)
+	assert.False(t, ok, "diffLights should report the caller should stop watching once ctx is cancelled")
+}
+
+func TestDiffSensors(t *testing.T) {
+	var unchanged Sensor
+	unchanged.Index = 1
+	unchanged.State.LastUpdated = "2020-01-01T00:00:00"
+
+	tests := []struct {
+		name    string
+		old     map[int]string
+		current []Sensor
+		want    []Event
+	}{
+		{
+			name:    "unchanged lastupdated emits nothing",
+			old:     map[int]string{1: "2020-01-01T00:00:00"},
+			current: []Sensor{unchanged},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan Event, len(tt.want)+1)
+			ok := diffSensors(tt.old, tt.current, events, context.Background())
+			close(events)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, collectEvents(events))
+		})
+	}
+}
+
+func TestDiffSensorsEmitsSensorTriggered(t *testing.T) {
+	var sensor Sensor
+	sensor.Index = 1
+	sensor.State.LastUpdated = "2020-01-01T00:00:01"
+	sensor.State.ButtonEvent = 1002
+
+	old := map[int]string{1: "2020-01-01T00:00:00"}
+
+	events := make(chan Event, 1)
+	ok := diffSensors(old, []Sensor{sensor}, events, context.Background())
+	close(events)
+
+	assert.True(t, ok)
+	assert.Equal(t, []Event{SensorTriggered{Index: 1, ButtonEvent: 1002}}, collectEvents(events))
+}
+
+// fakeBridgeServer serves /lights and /sensors from whatever lightsJSON and
+// sensorsJSON currently point at, letting a test change the bridge's
+// "state" between polls by swapping the pointed-to string.
+func fakeBridgeServer(t *testing.T, lightsJSON, sensorsJSON *atomic.Value) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/test-user/lights":
+			w.Write([]byte(lightsJSON.Load().(string)))
+		case r.URL.Path == "/api/test-user/sensors":
+			w.Write([]byte(sensorsJSON.Load().(string)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// TestWatchClosesChannelOnContextCancel drives Watch against a fake bridge
+// and confirms the returned channel is closed once ctx is cancelled, even
+// though the caller stopped draining it first.
+func TestWatchClosesChannelOnContextCancel(t *testing.T) {
+	var lightsJSON, sensorsJSON atomic.Value
+	lightsJSON.Store(`{"1":{"name":"Lamp","state":{"on":true,"reachable":true}}}`)
+	sensorsJSON.Store(`{}`)
+
+	server := fakeBridgeServer(t, &lightsJSON, &sensorsJSON)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	bridge := &Bridge{IPAddress: u.Host, Username: "test-user"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := bridge.Watch(ctx, 5*time.Millisecond)
+	assert.NoError(t, err)
+
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "events channel should be closed, not yield a stray value")
+	case <-time.After(time.Second):
+		t.Fatal("events channel was not closed within a second of ctx cancellation")
+	}
+}